@@ -2,33 +2,27 @@ package portforward
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"net"
 	"os"
 	"os/signal"
+	"sort"
 	"strings"
 	"syscall"
 
 	"github.com/docker/docker/api/types"
-	"github.com/docker/docker/api/types/container"
+	dockercontainer "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
 	"github.com/docker/go-connections/nat"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 
 	"github.com/iximiuz/cdebug/pkg/cliutil"
-	"github.com/iximiuz/cdebug/pkg/docker"
+	"github.com/iximiuz/cdebug/pkg/container"
 	"github.com/iximiuz/cdebug/pkg/jsonutil"
 	"github.com/iximiuz/cdebug/pkg/uuid"
 )
 
-// TODO:
-//   - parse ports args
-//   - handle non-default network case
-//   - handle exposing localhost ports
-//       cdebug exec --name helper --image socat <target> <target-port> <proxy-port>
-//       cdebug port-forward helper <host-port>:<proxy-port>
-
 // Possible options (kinda sorta as in ssh -L):
 //   - TARGET_PORT                                # binds TARGET_IP:TARGET_PORT to a random port on localhost
 //   - TARGET_IP:TARGET_PORT                      # The second form is needed to:
@@ -40,6 +34,14 @@ import (
 //
 //   - LOCAL_IP:LOCAL_PORT:TARGET_PORT            # similar to LOCAL_PORT:TARGET_PORT but LOCAL_IP is used instead of localhost
 //   - LOCAL_IP:LOCAL_PORT:TARGET_IP:TARGET_PORT
+//
+// Any of the above forms can carry a trailing /tcp or /udp (e.g. 5353:5353/udp)
+// to pick the protocol; TCP is the default. LOCAL_IP may be a hostname
+// (resolved at bind time) or a bracketed IPv6 literal, e.g. [::1]:8080:80.
+//
+// With --reverse, the spec mirrors the forward syntax but the direction
+// inverts (see reverse.go):
+//   - REMOTE_HOST:REMOTE_PORT:IN_CONTAINER_PORT
 
 const (
 	helperImage = "nixery.dev/shell/socat:latest"
@@ -53,21 +55,30 @@ type options struct {
 	forwardings []string
 	output      string
 	quiet       bool
+	runtime     string
+	network     string
+	reverse     bool
 }
 
 func NewCommand(cli cliutil.CLI) *cobra.Command {
 	var opts options
 
 	cmd := &cobra.Command{
-		Use:   "port-forward CONTAINER [[LOCAL_IP:]LOCAL_PORT:]TARGET_PORT [...]",
-		Short: `"Publish" one or more ports of an already running container`,
-		Args:  cobra.MinimumNArgs(2),
+		Use: "port-forward CONTAINER [[LOCAL_IP:]LOCAL_PORT:]TARGET_PORT [...]",
+		Short: `"Publish" one or more ports of an already running container, ` +
+			`or (with --reverse) expose a host port into it`,
+		Args: cobra.MinimumNArgs(2),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cli.SetQuiet(opts.quiet)
 
 			opts.target = args[0]
 			opts.forwardings = args[1:]
-			return cliutil.WrapStatusError(runPortForward(context.Background(), cli, &opts))
+
+			run := runPortForward
+			if opts.reverse {
+				run = runReversePortForward
+			}
+			return cliutil.WrapStatusError(run(context.Background(), cli, &opts))
 		},
 	}
 
@@ -90,87 +101,122 @@ func NewCommand(cli cliutil.CLI) *cobra.Command {
 		`Output format (plain text or JSON)`,
 	)
 
+	flags.StringVar(
+		&opts.runtime,
+		"runtime",
+		string(container.KindAuto),
+		`Container runtime to talk to ("docker", "podman", or "auto" to detect from CONTAINER_HOST/DOCKER_HOST)`,
+	)
+
+	flags.StringVar(
+		&opts.network,
+		"network",
+		"",
+		`Target's network to forward through (required when the target is attached to more than one)`,
+	)
+
+	flags.BoolVar(
+		&opts.reverse,
+		"reverse",
+		false,
+		`Reverse the direction: expose a REMOTE_HOST:REMOTE_PORT reachable from the host as IN_CONTAINER_PORT inside the target`,
+	)
+
 	return cmd
 }
 
 func runPortForward(ctx context.Context, cli cliutil.CLI, opts *options) error {
-	client, err := docker.NewClient(cli.AuxStream())
+	rt, err := container.New(ctx, container.Kind(opts.runtime), cli.AuxStream())
 	if err != nil {
-		return err
+		return fmt.Errorf("cannot initialize container runtime: %w", err)
 	}
 
-	target, err := client.ContainerInspect(ctx, opts.target)
+	target, err := rt.Inspect(ctx, opts.target)
 	if err != nil {
 		return err
 	}
 
 	// TODO: Check that target has at least 1 IP!
 
-	if err := client.ImagePullEx(ctx, helperImage, types.ImagePullOptions{}); err != nil {
+	if err := rt.ImagePull(ctx, helperImage); err != nil {
 		return fmt.Errorf("cannot pull port-forwarder helper image %q: %w", helperImage, err)
 	}
 
-	forwardings, err := parseForwardings(target, opts.forwardings)
+	networkName, err := resolveNetwork(target, opts.network)
 	if err != nil {
 		return err
 	}
 
-	exposedPorts, portBindings, err := nat.ParsePortSpecs(forwardings.toDockerPortSpecs())
+	forwardings, err := parseForwardings(target, networkName, opts.forwardings)
 	if err != nil {
 		return err
 	}
 
-	// TODO: Iterate over all forwardings.
-	resp, err := client.ContainerCreate(
-		ctx,
-		&container.Config{
+	// One helper container per forwarding: each gets its own socat
+	// invocation, so a TCP and a UDP forwarding (or several of either)
+	// can run side by side without a supervisor process inside the helper.
+	var helpers []portForwarder
+	for _, fwd := range forwardings {
+		cfg := &dockercontainer.Config{
 			Image:      helperImage,
 			Entrypoint: []string{"socat"},
-			Cmd: []string{
-				fmt.Sprintf("TCP-LISTEN:%s,fork", forwardings[0].targetPort),
-				fmt.Sprintf("TCP-CONNECT:%s:%s", forwardings[0].targetIP, forwardings[0].targetPort),
-			},
-			ExposedPorts: exposedPorts,
-		},
-		&container.HostConfig{
-			AutoRemove:   true,
-			PortBindings: portBindings,
-		},
-		nil,
-		nil,
-		"port-forwarder-"+uuid.ShortID(),
-	)
-	if err != nil {
-		return fmt.Errorf("cannot create port-forwarder container: %w", err)
-	}
+			Cmd:        fwd.socatArgs(),
+		}
+		hostCfg := &dockercontainer.HostConfig{
+			AutoRemove: true,
+		}
 
-	if err := client.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
-		return fmt.Errorf("cannot start port-forwarder container: %w", err)
-	}
+		if fwd.netnsJoin {
+			// The target's loopback is only reachable from inside its own
+			// network namespace. Joining that namespace outright would make
+			// the helper bind the very same port the target is already
+			// listening on, and Docker won't let a "container:<id>"-network
+			// container publish ports anyway. So the helper keeps its own
+			// network (and publishes normally below) but shares the
+			// target's PID namespace, letting socat's CONNECT side nsenter
+			// into the target's netns to dial its loopback (see
+			// forwarding.socatArgs).
+			hostCfg.PidMode = dockercontainer.PidMode("container:" + target.ID)
+			hostCfg.CapAdd = []string{"SYS_ADMIN"}
+		}
 
-	forwarder, err := client.ContainerInspect(ctx, resp.ID)
-	if err != nil {
-		return fmt.Errorf("cannot inspect forwarder container: %w", err)
+		exposedPorts, portBindings, err := nat.ParsePortSpecs([]string{fwd.toDockerPortSpec()})
+		if err != nil {
+			return err
+		}
+		cfg.ExposedPorts = exposedPorts
+		hostCfg.PortBindings = portBindings
+
+		id, err := rt.Create(
+			ctx,
+			cfg,
+			hostCfg,
+			networkName,
+			fmt.Sprintf("port-forwarder-%s-%s", fwd.proto, uuid.ShortID()),
+		)
+		if err != nil {
+			killHelpers(ctx, rt, helpers)
+			return fmt.Errorf("cannot create port-forwarder container: %w", err)
+		}
+
+		if err := rt.Start(ctx, id); err != nil {
+			killHelpers(ctx, rt, helpers)
+			return fmt.Errorf("cannot start port-forwarder container: %w", err)
+		}
+
+		info, err := rt.Inspect(ctx, id)
+		if err != nil {
+			killHelpers(ctx, rt, append(helpers, portForwarder{id: id}))
+			return fmt.Errorf("cannot inspect forwarder container: %w", err)
+		}
+
+		helpers = append(helpers, portForwarder{id: id, fwd: fwd, info: info})
 	}
 
-	// TODO: Multi-network support.
-	targetIP := target.NetworkSettings.Networks["bridge"].IPAddress
-	for remotePort, localBindings := range forwarder.NetworkSettings.Ports {
-		for _, binding := range localBindings {
-			switch opts.output {
-			case outFormatText:
-				local := net.JoinHostPort(binding.HostIP, binding.HostPort)
-				remote := targetIP + ":" + string(remotePort)
-				cli.PrintOut("Forwarding %s to %s's %s\n", local, target.Name[1:], remote)
-			case outFormatJSON:
-				cli.PrintOut(jsonutil.Dump(map[string]string{
-					"localHost":  binding.HostIP,
-					"localPort":  binding.HostPort,
-					"remoteHost": targetIP,
-					"remotePort": string(remotePort),
-				}))
-			default:
-				panic("unreachable!")
+	for _, h := range helpers {
+		for remotePort, localBindings := range h.info.NetworkSettings.Ports {
+			for _, binding := range localBindings {
+				printForwarding(cli, opts.output, binding.HostIP, binding.HostPort, target.Name[1:], h.fwd.targetIP, string(remotePort))
 			}
 		}
 	}
@@ -182,55 +228,250 @@ func runPortForward(ctx context.Context, cli cliutil.CLI, opts *options) error {
 	go func() {
 		for _ = range sigCh {
 			cli.PrintAux("Exiting...")
-
-			if err := client.ContainerKill(ctx, resp.ID, "KILL"); err != nil {
-				logrus.Debugf("Cannot kill forwarder container: %s", err)
-			}
+			killHelpers(ctx, rt, helpers)
 			break
 		}
 	}()
 
-	forwarderStatusCh, forwarderErrCh := client.ContainerWait(ctx, resp.ID, container.WaitConditionNotRunning)
-	// targetStatusCh, targetErrCh := client.ContainerWait(ctx, target.ID, container.WaitConditionNotRunning)
-	select {
-	case err := <-forwarderErrCh:
-		if err != nil {
+	doneCh := make(chan error, len(helpers))
+	for _, h := range helpers {
+		h := h
+		go func() {
+			statusCh, errCh := rt.Wait(ctx, h.id, dockercontainer.WaitConditionNotRunning)
+			select {
+			case err := <-errCh:
+				doneCh <- err
+			case <-statusCh:
+				doneCh <- nil
+			}
+		}()
+	}
+
+	for range helpers {
+		if err := <-doneCh; err != nil {
 			return fmt.Errorf("waiting for port-forwarder container failed: %w", err)
 		}
-	case <-forwarderStatusCh:
 	}
 
 	return nil
 }
 
+// portForwarder tracks one running socat helper container together with
+// the forwarding it was created for.
+type portForwarder struct {
+	id   string
+	fwd  forwarding
+	info types.ContainerJSON
+}
+
+// killHelpers kills every already-started helper container, logging (but
+// not failing on) individual kill errors. Used both on the interrupt path
+// and to tear down helpers started earlier in the same invocation when a
+// later one fails to start, so a partial failure doesn't leak AutoRemove
+// containers that nothing will ever signal again.
+func killHelpers(ctx context.Context, rt container.Runtime, helpers []portForwarder) {
+	for _, h := range helpers {
+		if err := rt.Kill(ctx, h.id, "KILL"); err != nil {
+			logrus.Debugf("Cannot kill forwarder container %s: %s", h.id, err)
+		}
+	}
+}
+
+func printForwarding(cli cliutil.CLI, output, localHost, localPort, targetName, remoteHost, remotePort string) {
+	switch output {
+	case outFormatText:
+		local := net.JoinHostPort(localHost, localPort)
+		remote := net.JoinHostPort(remoteHost, remotePort)
+		cli.PrintOut("Forwarding %s to %s's %s\n", local, targetName, remote)
+	case outFormatJSON:
+		cli.PrintOut(jsonutil.Dump(map[string]string{
+			"localHost":  localHost,
+			"localPort":  localPort,
+			"remoteHost": remoteHost,
+			"remotePort": remotePort,
+		}))
+	default:
+		panic("unreachable!")
+	}
+}
+
+// resolveNetwork picks which of the target's networks a forwarding should
+// go through. An explicit --network is validated against the target;
+// otherwise a target attached to exactly one network uses it implicitly,
+// and a target attached to several requires --network to disambiguate.
+// A target with no networks (e.g. NetworkMode: host) resolves to "".
+func resolveNetwork(target types.ContainerJSON, requested string) (string, error) {
+	networks := target.NetworkSettings.Networks
+
+	if requested == "" && target.HostConfig != nil && target.HostConfig.NetworkMode.IsHost() {
+		return "", nil
+	}
+
+	if requested != "" {
+		if _, ok := networks[requested]; !ok {
+			return "", fmt.Errorf(
+				"target %q is not attached to network %q (available: %s)",
+				target.Name[1:], requested, strings.Join(networkNames(networks), ", "),
+			)
+		}
+		return requested, nil
+	}
+
+	switch len(networks) {
+	case 0:
+		return "", nil
+	case 1:
+		for name := range networks {
+			return name, nil
+		}
+	}
+
+	return "", fmt.Errorf(
+		"target %q is attached to multiple networks (%s); pick one with --network",
+		target.Name[1:], strings.Join(networkNames(networks), ", "),
+	)
+}
+
+func networkNames(networks map[string]*network.EndpointSettings) []string {
+	names := make([]string, 0, len(networks))
+	for name := range networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+const (
+	protoTCP = "tcp"
+	protoUDP = "udp"
+)
+
 type forwarding struct {
 	localIP    string
 	localPort  string
 	targetIP   string
 	targetPort string
+	proto      string
+
+	// netnsJoin indicates the target's port is only reachable via its own
+	// loopback (127.0.0.1): the helper still publishes normally on its own
+	// network, but its CONNECT side has to nsenter into the target's
+	// network namespace (HostConfig.PidMode: container:<id>) to dial it.
+	netnsJoin bool
+}
+
+// socatArgs returns the socat invocation (entrypoint args) that proxies a
+// single forwarding through the helper container.
+func (f forwarding) socatArgs() []string {
+	fam := addrFamily(f.targetIP)
+
+	if f.proto == protoUDP {
+		udpFam := fam
+		if udpFam == "" {
+			udpFam = "4"
+		}
+		listen := fmt.Sprintf("UDP%s-RECVFROM:%s,fork,reuseaddr", udpFam, f.targetPort)
+		if f.netnsJoin {
+			return []string{listen, f.netnsConnectArg("UDP" + udpFam)}
+		}
+		return []string{listen, fmt.Sprintf("UDP%s-SENDTO:%s:%s", udpFam, f.targetIP, f.targetPort)}
+	}
+
+	listen := fmt.Sprintf("TCP%s-LISTEN:%s,fork", fam, f.targetPort)
+	if f.netnsJoin {
+		return []string{listen, f.netnsConnectArg("TCP" + fam)}
+	}
+	return []string{listen, fmt.Sprintf("TCP%s-CONNECT:%s:%s", fam, f.targetIP, f.targetPort)}
+}
+
+// netnsConnectArg builds the CONNECT-side socat address for a netnsJoin
+// forwarding. The target's loopback is only reachable from inside its own
+// network namespace, so instead of dialing it directly, an inner socat is
+// nsentered into that namespace (the helper shares the target's PID
+// namespace, see runPortForward) and piped back over EXEC/STDIO.
+func (f forwarding) netnsConnectArg(proto string) string {
+	return fmt.Sprintf(
+		`EXEC:nsenter -t 1 -n -- socat STDIO %s-CONNECT\:%s\:%s`,
+		proto, f.targetIP, f.targetPort,
+	)
+}
+
+func (f forwarding) toDockerPortSpec() string {
+	// ip:hostPort:containerPort[/proto] | ip::containerPort[/proto] | hostPort:containerPort[/proto] | containerPort[/proto]
+	localIP := f.localIP
+	if addrFamily(localIP) == "6" {
+		localIP = "[" + localIP + "]"
+	}
+	return fmt.Sprintf("%s:%s:%s/%s", localIP, f.localPort, f.targetPort, f.proto)
 }
 
 type forwardingList []forwarding
 
-func (list forwardingList) toDockerPortSpecs() []string {
-	// ip:hostPort:containerPort | ip::containerPort | hostPort:containerPort | containerPort
-	var spec []string
-	for _, f := range list {
-		spec = append(spec, fmt.Sprintf("%s:%s:%s", f.localIP, f.localPort, f.targetPort))
+// splitProto strips and validates a trailing "/tcp" or "/udp" suffix,
+// defaulting to TCP when none is given.
+func splitProto(spec string) (string, string, error) {
+	portSpec, proto, found := strings.Cut(spec, "/")
+	if !found {
+		return spec, protoTCP, nil
+	}
+
+	proto = strings.ToLower(proto)
+	if proto != protoTCP && proto != protoUDP {
+		return "", "", fmt.Errorf("unsupported protocol %q (expected %q or %q)", proto, protoTCP, protoUDP)
 	}
-	return spec
+	return portSpec, proto, nil
 }
 
 func parseForwardings(
 	target types.ContainerJSON,
+	networkName string,
 	forwardings []string,
 ) (forwardingList, error) {
 	var list forwardingList
 
-	targetIP := target.NetworkSettings.Networks["bridge"].IPAddress
+	hostNetwork := target.HostConfig != nil && target.HostConfig.NetworkMode.IsHost()
+
+	var targetIP string
+	if networkName != "" {
+		targetIP = target.NetworkSettings.Networks[networkName].IPAddress
+	}
+
+	// loopbackOnly is true when the target has nothing reachable through a
+	// regular network (host networking, or no network attached at all), so
+	// every bare TARGET_PORT/LOCAL_PORT:TARGET_PORT spec implicitly means
+	// "the target's own 127.0.0.1".
+	loopbackOnly := hostNetwork || targetIP == ""
+	if loopbackOnly {
+		targetIP = "127.0.0.1"
+	}
+
+	// validTargetIP is scoped to the selected network: the helper is only
+	// ever attached to networkName (see runPortForward), so a TARGET_IP
+	// that's merely valid on some other network of the target would be
+	// accepted here and then simply fail to connect.
+	validTargetIP := func(ip string) error {
+		if ip == "127.0.0.1" {
+			return nil
+		}
+		if n, ok := target.NetworkSettings.Networks[networkName]; ok {
+			if n.IPAddress == ip || n.GlobalIPv6Address == ip {
+				return nil
+			}
+		}
+		return fmt.Errorf("%q is not %q's address on network %q (and isn't 127.0.0.1)", ip, target.Name[1:], networkName)
+	}
+
+	for _, spec := range forwardings {
+		f, proto, err := splitProto(spec)
+		if err != nil {
+			return nil, err
+		}
+
+		parts, err := tokenizeSpec(f)
+		if err != nil {
+			return nil, err
+		}
 
-	for _, f := range forwardings {
-		parts := strings.Split(f, ":")
 		if len(parts) == 1 {
 			// Case 1: TARGET_PORT
 
@@ -239,12 +480,12 @@ func parseForwardings(
 				return nil, err
 			}
 
-			// TODO: if "target has more than 1 IP" return err
-
 			list = append(list, forwarding{
 				localIP:    "127.0.0.1",
 				targetPort: parts[0],
 				targetIP:   targetIP,
+				proto:      proto,
+				netnsJoin:  loopbackOnly,
 			})
 			continue
 		}
@@ -253,31 +494,93 @@ func parseForwardings(
 			if _, err := nat.ParsePort(parts[0]); err == nil {
 				// Case 2: LOCAL_PORT:TARGET_PORT
 
-				// TODO: if "target has more than 1 IP" return err
-
 				list = append(list, forwarding{
 					localPort:  parts[0],
 					localIP:    "127.0.0.1",
 					targetPort: parts[1],
 					targetIP:   targetIP,
+					proto:      proto,
+					netnsJoin:  loopbackOnly,
 				})
 			} else {
 				// Case 3: TARGET_IP:TARGET_PORT
 
-				// TODO: if "parts[0] not in target IP list" return err
+				if err := validTargetIP(parts[0]); err != nil {
+					return nil, err
+				}
 
 				list = append(list, forwarding{
 					localIP:    "127.0.0.1",
 					targetPort: parts[1],
 					targetIP:   parts[0],
+					proto:      proto,
+					netnsJoin:  parts[0] == "127.0.0.1",
+				})
+			}
+			continue
+		}
+
+		if len(parts) == 3 {
+			if _, err := nat.ParsePort(parts[0]); err == nil {
+				// Case 4: LOCAL_PORT:TARGET_IP:TARGET_PORT
+
+				if err := validTargetIP(parts[1]); err != nil {
+					return nil, err
+				}
+
+				list = append(list, forwarding{
+					localPort:  parts[0],
+					localIP:    "127.0.0.1",
+					targetIP:   parts[1],
+					targetPort: parts[2],
+					proto:      proto,
+					netnsJoin:  parts[1] == "127.0.0.1",
 				})
+				continue
+			}
+
+			// Case 5: LOCAL_IP:LOCAL_PORT:TARGET_PORT
+
+			localIP, err := resolveLocalIP(parts[0])
+			if err != nil {
+				return nil, err
 			}
+
+			list = append(list, forwarding{
+				localIP:    localIP,
+				localPort:  parts[1],
+				targetIP:   targetIP,
+				targetPort: parts[2],
+				proto:      proto,
+				netnsJoin:  loopbackOnly,
+			})
+			continue
+		}
+
+		if len(parts) == 4 {
+			// Case 6: LOCAL_IP:LOCAL_PORT:TARGET_IP:TARGET_PORT
+
+			localIP, err := resolveLocalIP(parts[0])
+			if err != nil {
+				return nil, err
+			}
+			if err := validTargetIP(parts[2]); err != nil {
+				return nil, err
+			}
+
+			list = append(list, forwarding{
+				localIP:    localIP,
+				localPort:  parts[1],
+				targetIP:   parts[2],
+				targetPort: parts[3],
+				proto:      proto,
+				netnsJoin:  parts[2] == "127.0.0.1",
+			})
 			continue
 		}
 
-		// TODO: other cases
-		return nil, errors.New("implement me")
+		return nil, fmt.Errorf("invalid forwarding spec %q", spec)
 	}
 
 	return list, nil
-}
\ No newline at end of file
+}
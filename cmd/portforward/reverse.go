@@ -0,0 +1,214 @@
+package portforward
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/docker/docker/api/types"
+	dockercontainer "github.com/docker/docker/api/types/container"
+	"github.com/docker/go-connections/nat"
+
+	"github.com/iximiuz/cdebug/pkg/cliutil"
+	"github.com/iximiuz/cdebug/pkg/container"
+	"github.com/iximiuz/cdebug/pkg/uuid"
+)
+
+// runReversePortForward implements `port-forward --reverse`: instead of
+// publishing a target's port on the host, it exposes a host-reachable
+// REMOTE_HOST:REMOTE_PORT as IN_CONTAINER_PORT inside the target. It reuses
+// the same socat-helper architecture as the forward direction, just with
+// the helper joining the target's network namespace and socat's LISTEN and
+// CONNECT sides swapped.
+func runReversePortForward(ctx context.Context, cli cliutil.CLI, opts *options) error {
+	rt, err := container.New(ctx, container.Kind(opts.runtime), cli.AuxStream())
+	if err != nil {
+		return fmt.Errorf("cannot initialize container runtime: %w", err)
+	}
+
+	target, err := rt.Inspect(ctx, opts.target)
+	if err != nil {
+		return err
+	}
+
+	if err := rt.ImagePull(ctx, helperImage); err != nil {
+		return fmt.Errorf("cannot pull port-forwarder helper image %q: %w", helperImage, err)
+	}
+
+	networkName, err := resolveNetwork(target, opts.network)
+	if err != nil {
+		return err
+	}
+
+	reversals, err := parseReverseForwardings(target, networkName, opts.forwardings)
+	if err != nil {
+		return err
+	}
+
+	var helpers []portForwarder
+	for _, rev := range reversals {
+		id, err := rt.Create(
+			ctx,
+			&dockercontainer.Config{
+				Image:      helperImage,
+				Entrypoint: []string{"socat"},
+				Cmd:        rev.socatArgs(),
+			},
+			&dockercontainer.HostConfig{
+				AutoRemove: true,
+				// The listening side of a reverse forward has to live
+				// inside the target, so the helper joins its netns instead
+				// of getting its own network and publishing ports.
+				NetworkMode: dockercontainer.NetworkMode("container:" + target.ID),
+			},
+			// The helper already joins the target's netns above; it has no
+			// network of its own to attach to --network with.
+			"",
+			fmt.Sprintf("port-reverse-forwarder-%s-%s", rev.proto, uuid.ShortID()),
+		)
+		if err != nil {
+			killHelpers(ctx, rt, helpers)
+			return fmt.Errorf("cannot create reverse-port-forwarder container: %w", err)
+		}
+
+		if err := rt.Start(ctx, id); err != nil {
+			killHelpers(ctx, rt, append(helpers, portForwarder{id: id}))
+			return fmt.Errorf("cannot start reverse-port-forwarder container: %w", err)
+		}
+
+		helpers = append(helpers, portForwarder{id: id, fwd: forwarding{
+			targetIP:   rev.containerPort,
+			targetPort: rev.containerPort,
+			proto:      rev.proto,
+		}})
+
+		printForwarding(cli, opts.output, "127.0.0.1", rev.containerPort, target.Name[1:], rev.remoteHost, rev.remotePort)
+	}
+
+	sigCh := make(chan os.Signal, 128)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	defer close(sigCh)
+
+	go func() {
+		for range sigCh {
+			cli.PrintAux("Exiting...")
+			killHelpers(ctx, rt, helpers)
+			break
+		}
+	}()
+
+	doneCh := make(chan error, len(helpers))
+	for _, h := range helpers {
+		h := h
+		go func() {
+			statusCh, errCh := rt.Wait(ctx, h.id, dockercontainer.WaitConditionNotRunning)
+			select {
+			case err := <-errCh:
+				doneCh <- err
+			case <-statusCh:
+				doneCh <- nil
+			}
+		}()
+	}
+
+	for range helpers {
+		if err := <-doneCh; err != nil {
+			return fmt.Errorf("waiting for reverse-port-forwarder container failed: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// reverseForwarding describes exposing a host/remote address as a port
+// inside the target container.
+type reverseForwarding struct {
+	remoteHost    string
+	remotePort    string
+	containerPort string
+	proto         string
+}
+
+// socatArgs returns the socat invocation that listens inside the target
+// and connects back out to the remote host.
+func (r reverseForwarding) socatArgs() []string {
+	if r.proto == protoUDP {
+		return []string{
+			fmt.Sprintf("UDP4-LISTEN:%s,fork,reuseaddr", r.containerPort),
+			fmt.Sprintf("UDP4-SENDTO:%s:%s", r.remoteHost, r.remotePort),
+		}
+	}
+	return []string{
+		fmt.Sprintf("TCP-LISTEN:%s,fork", r.containerPort),
+		fmt.Sprintf("TCP-CONNECT:%s:%s", r.remoteHost, r.remotePort),
+	}
+}
+
+func parseReverseForwardings(
+	target types.ContainerJSON,
+	networkName string,
+	forwardings []string,
+) ([]reverseForwarding, error) {
+	var list []reverseForwarding
+
+	for _, spec := range forwardings {
+		f, proto, err := splitProto(spec)
+		if err != nil {
+			return nil, err
+		}
+
+		parts, err := tokenizeSpec(f)
+		if err != nil {
+			return nil, err
+		}
+		if len(parts) != 3 {
+			return nil, fmt.Errorf(
+				"invalid reverse forwarding spec %q (expected REMOTE_HOST:REMOTE_PORT:IN_CONTAINER_PORT)", spec,
+			)
+		}
+
+		remoteHost, remotePort, containerPort := parts[0], parts[1], parts[2]
+
+		if _, err := nat.ParsePort(remotePort); err != nil {
+			return nil, fmt.Errorf("invalid REMOTE_PORT %q: %w", remotePort, err)
+		}
+		if _, err := nat.ParsePort(containerPort); err != nil {
+			return nil, fmt.Errorf("invalid IN_CONTAINER_PORT %q: %w", containerPort, err)
+		}
+
+		list = append(list, reverseForwarding{
+			remoteHost:    resolveReverseHost(target, networkName, remoteHost),
+			remotePort:    remotePort,
+			containerPort: containerPort,
+			proto:         proto,
+		})
+	}
+
+	if len(list) == 0 {
+		return nil, errors.New("at least one REMOTE_HOST:REMOTE_PORT:IN_CONTAINER_PORT is required")
+	}
+
+	return list, nil
+}
+
+// resolveReverseHost translates "localhost"/"host.docker.internal" into an
+// address that's actually reachable from inside the target: the target's
+// network gateway when known, falling back to the host.docker.internal
+// special DNS name otherwise. Any other REMOTE_HOST is used verbatim and
+// resolved by socat at connect time.
+func resolveReverseHost(target types.ContainerJSON, networkName, remoteHost string) string {
+	if remoteHost != "localhost" && remoteHost != "host.docker.internal" {
+		return remoteHost
+	}
+
+	if networkName != "" {
+		if n, ok := target.NetworkSettings.Networks[networkName]; ok && n.Gateway != "" {
+			return n.Gateway
+		}
+	}
+
+	return "host.docker.internal"
+}
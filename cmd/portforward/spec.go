@@ -0,0 +1,93 @@
+package portforward
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// tokenizeSpec splits a forwarding/reverse-forwarding spec on ':', treating
+// a bracketed literal such as [::1] or [fd00::2] as a single token (with
+// the brackets stripped) so an IPv6 address survives the split intact.
+func tokenizeSpec(spec string) ([]string, error) {
+	var tokens []string
+
+	for len(spec) > 0 {
+		if spec[0] != '[' {
+			idx := strings.IndexByte(spec, ':')
+			if idx < 0 {
+				tokens = append(tokens, spec)
+				break
+			}
+			tokens = append(tokens, spec[:idx])
+			spec = spec[idx+1:]
+			continue
+		}
+
+		end := strings.IndexByte(spec, ']')
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated IPv6 literal in %q", spec)
+		}
+		literal := spec[:end+1]
+		tokens = append(tokens, spec[1:end])
+		spec = spec[end+1:]
+
+		if len(spec) == 0 {
+			break
+		}
+		if spec[0] != ':' {
+			return nil, fmt.Errorf("expected ':' after %q in forwarding spec", literal)
+		}
+		spec = spec[1:]
+	}
+
+	return tokens, nil
+}
+
+// resolveLocalIP validates that hostOrIP (a literal IP or a DNS name)
+// resolves to an address assigned to a local network interface, returning
+// that address. A LOCAL_IP that doesn't belong to this host can't be bound
+// by the forwarder, so this is checked eagerly instead of failing later
+// with a confusing "address not available" error from the container runtime.
+func resolveLocalIP(hostOrIP string) (string, error) {
+	if ip := net.ParseIP(hostOrIP); ip != nil {
+		if !isLocalIP(ip) {
+			return "", fmt.Errorf("LOCAL_IP %s is not assigned to a local interface", hostOrIP)
+		}
+		return hostOrIP, nil
+	}
+
+	ips, err := net.LookupIP(hostOrIP)
+	if err != nil {
+		return "", fmt.Errorf("cannot resolve LOCAL_IP %q: %w", hostOrIP, err)
+	}
+	for _, ip := range ips {
+		if isLocalIP(ip) {
+			return ip.String(), nil
+		}
+	}
+	return "", fmt.Errorf("LOCAL_IP %q does not resolve to any local interface address", hostOrIP)
+}
+
+func isLocalIP(ip net.IP) bool {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false
+	}
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if ok && ipNet.IP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// addrFamily returns the socat address-family suffix ("6" for an IPv6
+// literal, "" otherwise) to pick between e.g. TCP-CONNECT and TCP6-CONNECT.
+func addrFamily(ip string) string {
+	if strings.Contains(ip, ":") {
+		return "6"
+	}
+	return ""
+}
@@ -0,0 +1,77 @@
+package container
+
+import (
+	"context"
+	"io"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+
+	"github.com/iximiuz/cdebug/pkg/docker"
+)
+
+// dockerRuntime adapts pkg/docker.Client (the Docker Engine API client
+// already used throughout cdebug) to the Runtime interface.
+type dockerRuntime struct {
+	client *docker.Client
+}
+
+func newDockerRuntime(auxStream io.Writer) (Runtime, error) {
+	client, err := docker.NewClient(auxStream)
+	if err != nil {
+		return nil, err
+	}
+	return &dockerRuntime{client: client}, nil
+}
+
+func (r *dockerRuntime) Kind() Kind {
+	return KindDocker
+}
+
+func (r *dockerRuntime) Inspect(ctx context.Context, nameOrID string) (types.ContainerJSON, error) {
+	return r.client.ContainerInspect(ctx, nameOrID)
+}
+
+func (r *dockerRuntime) ImagePull(ctx context.Context, image string) error {
+	return r.client.ImagePullEx(ctx, image, types.ImagePullOptions{})
+}
+
+func (r *dockerRuntime) Create(
+	ctx context.Context,
+	cfg *container.Config,
+	hostCfg *container.HostConfig,
+	networkName string,
+	name string,
+) (string, error) {
+	var netCfg *network.NetworkingConfig
+	if networkName != "" {
+		netCfg = &network.NetworkingConfig{
+			EndpointsConfig: map[string]*network.EndpointSettings{
+				networkName: {},
+			},
+		}
+	}
+
+	resp, err := r.client.ContainerCreate(ctx, cfg, hostCfg, netCfg, nil, name)
+	if err != nil {
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+func (r *dockerRuntime) Start(ctx context.Context, id string) error {
+	return r.client.ContainerStart(ctx, id, types.ContainerStartOptions{})
+}
+
+func (r *dockerRuntime) Kill(ctx context.Context, id string, signal string) error {
+	return r.client.ContainerKill(ctx, id, signal)
+}
+
+func (r *dockerRuntime) Wait(
+	ctx context.Context,
+	id string,
+	cond container.WaitCondition,
+) (<-chan container.WaitResponse, <-chan error) {
+	return r.client.ContainerWait(ctx, id, cond)
+}
@@ -0,0 +1,302 @@
+package container
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/go-connections/nat"
+)
+
+const podmanAPIVersion = "v4.0.0"
+
+// podmanRuntime talks to Podman's libpod REST API over its unix socket
+// (either the rootless per-user socket or the system one).
+type podmanRuntime struct {
+	http    *http.Client
+	baseURL string
+}
+
+func newPodmanRuntime(ctx context.Context) (Runtime, error) {
+	var lastErr error
+	for _, sock := range podmanSocketCandidates() {
+		rt, err := newPodmanRuntimeAt(ctx, "unix://"+sock)
+		if err == nil {
+			return rt, nil
+		}
+		lastErr = err
+	}
+	return nil, fmt.Errorf("cannot reach podman socket: %w", lastErr)
+}
+
+func newPodmanRuntimeAt(ctx context.Context, host string) (Runtime, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid podman host %q: %w", host, err)
+	}
+	if u.Scheme != "unix" {
+		return nil, fmt.Errorf("unsupported podman host scheme %q (only unix:// is supported)", u.Scheme)
+	}
+	sockPath := u.Path
+
+	httpClient := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", sockPath)
+			},
+		},
+		Timeout: 30 * time.Second,
+	}
+
+	rt := &podmanRuntime{
+		http:    httpClient,
+		baseURL: "http://podman/" + podmanAPIVersion + "/libpod",
+	}
+
+	pingCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	if _, err := rt.do(pingCtx, http.MethodGet, "/_ping", nil); err != nil {
+		return nil, fmt.Errorf("cannot ping podman socket %q: %w", sockPath, err)
+	}
+
+	return rt, nil
+}
+
+func (r *podmanRuntime) Kind() Kind {
+	return KindPodman
+}
+
+func (r *podmanRuntime) do(ctx context.Context, method, path string, body io.Reader) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, r.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := r.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("podman API %s %s: %s: %s", method, path, resp.Status, bytes.TrimSpace(data))
+	}
+	return data, nil
+}
+
+// libpodInspect is the handful of fields from libpod's container inspect
+// payload that cdebug's port-forward command actually needs. Podman's
+// native inspect shape differs from the Docker Engine's, so we map it onto
+// types.ContainerJSON ourselves instead of pulling in the full libpod API
+// bindings for a couple of fields.
+type libpodInspect struct {
+	ID         string `json:"Id"`
+	Name       string `json:"Name"`
+	HostConfig struct {
+		NetworkMode string `json:"NetworkMode"`
+	} `json:"HostConfig"`
+	NetworkSettings struct {
+		Networks map[string]struct {
+			IPAddress string `json:"IPAddress"`
+			Gateway   string `json:"Gateway"`
+		} `json:"Networks"`
+	} `json:"NetworkSettings"`
+}
+
+func (r *podmanRuntime) Inspect(ctx context.Context, nameOrID string) (types.ContainerJSON, error) {
+	data, err := r.do(ctx, http.MethodGet, "/containers/"+url.PathEscape(nameOrID)+"/json", nil)
+	if err != nil {
+		return types.ContainerJSON{}, err
+	}
+
+	var raw libpodInspect
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return types.ContainerJSON{}, fmt.Errorf("cannot decode podman inspect response: %w", err)
+	}
+
+	networks := map[string]*network.EndpointSettings{}
+	for name, n := range raw.NetworkSettings.Networks {
+		networks[name] = &network.EndpointSettings{
+			IPAddress: n.IPAddress,
+			Gateway:   n.Gateway,
+		}
+	}
+
+	// Rootless Podman containers without a custom CNI network (reported
+	// HostConfig.NetworkMode "slirp4netns") run their real networking in
+	// the per-pod "infra" container, so there's no bridge-attached IP on
+	// the target itself and `networks` above comes back empty.
+	// parseForwardings already treats an empty network list the same as
+	// host networking (loopback-only), so no extra signal needs to flow
+	// through HostConfig.NetworkMode for that case.
+
+	return types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{
+			ID: raw.ID,
+			// libpod reports the bare name; Docker's shape has a leading "/".
+			Name: "/" + raw.Name,
+			HostConfig: &container.HostConfig{
+				NetworkMode: container.NetworkMode(raw.HostConfig.NetworkMode),
+			},
+		},
+		NetworkSettings: &types.NetworkSettings{
+			Networks: networks,
+		},
+	}, nil
+}
+
+func (r *podmanRuntime) ImagePull(ctx context.Context, image string) error {
+	_, err := r.do(ctx, http.MethodPost, "/images/pull?reference="+url.QueryEscape(image), nil)
+	return err
+}
+
+func (r *podmanRuntime) Create(
+	ctx context.Context,
+	cfg *container.Config,
+	hostCfg *container.HostConfig,
+	networkName string,
+	name string,
+) (string, error) {
+	create := map[string]interface{}{
+		"name":         name,
+		"image":        cfg.Image,
+		"entrypoint":   cfg.Entrypoint,
+		"command":      cfg.Cmd,
+		"netns":        netnsSpec(hostCfg.NetworkMode),
+		"portmappings": portMappings(cfg.ExposedPorts, hostCfg.PortBindings),
+		"remove":       hostCfg.AutoRemove,
+	}
+	if networkName != "" {
+		create["networks"] = map[string]interface{}{networkName: map[string]interface{}{}}
+	}
+	if strings.HasPrefix(string(hostCfg.PidMode), "container:") {
+		create["pidns"] = map[string]string{
+			"nsmode": "container",
+			"value":  strings.TrimPrefix(string(hostCfg.PidMode), "container:"),
+		}
+	}
+	if len(hostCfg.CapAdd) > 0 {
+		create["cap_add"] = []string(hostCfg.CapAdd)
+	}
+
+	body, err := json.Marshal(create)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := r.do(ctx, http.MethodPost, "/containers/create", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+
+	var created struct {
+		ID string `json:"Id"`
+	}
+	if err := json.Unmarshal(data, &created); err != nil {
+		return "", fmt.Errorf("cannot decode podman create response: %w", err)
+	}
+	return created.ID, nil
+}
+
+func (r *podmanRuntime) Start(ctx context.Context, id string) error {
+	_, err := r.do(ctx, http.MethodPost, "/containers/"+url.PathEscape(id)+"/start", nil)
+	return err
+}
+
+func (r *podmanRuntime) Kill(ctx context.Context, id string, signal string) error {
+	_, err := r.do(ctx, http.MethodPost, "/containers/"+url.PathEscape(id)+"/kill?signal="+url.QueryEscape(signal), nil)
+	return err
+}
+
+func (r *podmanRuntime) Wait(
+	ctx context.Context,
+	id string,
+	cond container.WaitCondition,
+) (<-chan container.WaitResponse, <-chan error) {
+	statusCh := make(chan container.WaitResponse, 1)
+	errCh := make(chan error, 1)
+
+	go func() {
+		data, err := r.do(ctx, http.MethodPost, "/containers/"+url.PathEscape(id)+"/wait?condition="+url.QueryEscape(libpodWaitCondition(cond)), nil)
+		if err != nil {
+			errCh <- err
+			return
+		}
+
+		var exitCode int64
+		if err := json.Unmarshal(data, &exitCode); err != nil {
+			errCh <- fmt.Errorf("cannot decode podman wait response: %w", err)
+			return
+		}
+		statusCh <- container.WaitResponse{StatusCode: exitCode}
+	}()
+
+	return statusCh, errCh
+}
+
+// libpodWaitCondition translates a Docker Engine wait condition into the
+// vocabulary libpod's /containers/{id}/wait endpoint accepts (see
+// define.StringToContainerStatus in containers/podman): Docker's
+// "not-running"/"next-exit" both mean "wait until it's no longer running",
+// which is libpod's "exited".
+func libpodWaitCondition(cond container.WaitCondition) string {
+	switch cond {
+	case container.WaitConditionRemoved:
+		return "removed"
+	default:
+		return "exited"
+	}
+}
+
+// netnsSpec translates a Docker-style NetworkMode into the netns spec
+// libpod's container-create endpoint expects (notably "container:<id>" to
+// join the target's namespace, as the reverse-forward helper does).
+func netnsSpec(mode container.NetworkMode) map[string]string {
+	if mode == "" {
+		return nil
+	}
+	if strings.HasPrefix(string(mode), "container:") {
+		return map[string]string{
+			"nsmode": "container",
+			"value":  strings.TrimPrefix(string(mode), "container:"),
+		}
+	}
+	return map[string]string{"nsmode": "bridge"}
+}
+
+func portMappings(exposed nat.PortSet, bindings nat.PortMap) []map[string]interface{} {
+	var mappings []map[string]interface{}
+	for port := range exposed {
+		for _, binding := range bindings[port] {
+			// binding.HostPort is "" when the port is auto-assigned, which
+			// conveniently matches libpod's zero-value "pick a free port".
+			hostPort, _ := strconv.Atoi(binding.HostPort)
+			mappings = append(mappings, map[string]interface{}{
+				"container_port": port.Int(),
+				"host_ip":        binding.HostIP,
+				"host_port":      hostPort,
+				"protocol":       port.Proto(),
+			})
+		}
+	}
+	return mappings
+}
@@ -0,0 +1,109 @@
+// Package container provides a runtime-agnostic abstraction over the
+// container engine APIs cdebug needs to drive (Docker Engine and Podman's
+// libpod-compatible REST API), so commands like port-forward don't have to
+// hardcode dockerd as the only supported backend.
+package container
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+)
+
+// Kind identifies a supported container runtime backend.
+type Kind string
+
+const (
+	KindDocker Kind = "docker"
+	KindPodman Kind = "podman"
+
+	// KindAuto asks detect to pick a backend based on the environment.
+	KindAuto Kind = "auto"
+)
+
+// Runtime is the subset of a container engine API that cdebug commands need
+// in order to create, inspect, and tear down helper containers regardless of
+// whether the target is managed by dockerd or by Podman.
+type Runtime interface {
+	// Kind returns the concrete backend this Runtime talks to.
+	Kind() Kind
+
+	Inspect(ctx context.Context, nameOrID string) (types.ContainerJSON, error)
+
+	ImagePull(ctx context.Context, image string) error
+
+	// networkName optionally attaches the created container to that
+	// network (e.g. the target's selected --network) before it starts;
+	// pass "" to leave the container on whatever network hostCfg implies
+	// (the backend's default, or none when NetworkMode/PidMode already
+	// ties it to another container's namespace).
+	Create(
+		ctx context.Context,
+		cfg *container.Config,
+		hostCfg *container.HostConfig,
+		networkName string,
+		name string,
+	) (id string, err error)
+
+	Start(ctx context.Context, id string) error
+
+	Kill(ctx context.Context, id string, signal string) error
+
+	Wait(
+		ctx context.Context,
+		id string,
+		cond container.WaitCondition,
+	) (<-chan container.WaitResponse, <-chan error)
+}
+
+// New creates a Runtime for the requested kind. KindAuto looks at
+// CONTAINER_HOST/DOCKER_HOST to decide between Docker and Podman; the
+// well-known Podman socket paths are only tried for KindPodman.
+func New(ctx context.Context, kind Kind, auxStream io.Writer) (Runtime, error) {
+	switch kind {
+	case KindDocker:
+		return newDockerRuntime(auxStream)
+	case KindPodman:
+		return newPodmanRuntime(ctx)
+	case KindAuto, "":
+		return detect(ctx, auxStream)
+	default:
+		return nil, fmt.Errorf("unknown container runtime %q (expected %q or %q)", kind, KindDocker, KindPodman)
+	}
+}
+
+// detect picks a backend without the caller having to pass --runtime.
+// It only opts into Podman when the environment explicitly says so
+// (CONTAINER_HOST, or a Podman-flavored DOCKER_HOST); otherwise it goes
+// straight to Docker, so having a Podman socket reachable on a host that
+// also runs dockerd doesn't silently steal the default runtime.
+func detect(ctx context.Context, auxStream io.Writer) (Runtime, error) {
+	if host := os.Getenv("CONTAINER_HOST"); host != "" || looksLikePodmanSocket(os.Getenv("DOCKER_HOST")) {
+		if host == "" {
+			host = os.Getenv("DOCKER_HOST")
+		}
+		if rt, err := newPodmanRuntimeAt(ctx, host); err == nil {
+			return rt, nil
+		}
+	}
+
+	return newDockerRuntime(auxStream)
+}
+
+func looksLikePodmanSocket(dockerHost string) bool {
+	return strings.Contains(dockerHost, "podman")
+}
+
+func podmanSocketCandidates() []string {
+	var candidates []string
+	if uid := os.Getuid(); uid != 0 {
+		candidates = append(candidates, fmt.Sprintf("/run/user/%d/podman/podman.sock", uid))
+	}
+	candidates = append(candidates, "/run/podman/podman.sock")
+	return candidates
+}